@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// openInput opens path for reading, treating "-" as stdin.
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+// readAllInput reads the full contents of path, treating "-" as stdin.
+// Region extraction and headerless-ROM detection both need random
+// access to the whole file, so decode reads it into memory rather than
+// streaming it.
+func readAllInput(path string) ([]byte, error) {
+	f, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// emit writes v to stdout using the selected --format, falling back to a
+// caller-supplied text renderer when outputFormat is "text".
+func emit(v interface{}, textRender func()) {
+	switch outputFormat {
+	case "json":
+		out, err := json.MarshalIndent(v, "", "  ")
+		check_error(err, "** ERROR: Encoding JSON.\n\n")
+		fmt.Println(string(out))
+	case "yaml":
+		out, err := yaml.Marshal(v)
+		check_error(err, "** ERROR: Encoding YAML.\n\n")
+		fmt.Print(string(out))
+	default:
+		textRender()
+	}
+}
+
+// DecodeResult is everything cmdDecode knows about one file, and what
+// gets emitted as a single JSON/YAML document with --format.
+type DecodeResult struct {
+	Path        string        `json:"path" yaml:"path"`
+	Headerless  bool          `json:"headerless,omitempty" yaml:"headerless,omitempty"`
+	Header      DecodedHeader `json:"header" yaml:"header"`
+	TrainerSHA1 string        `json:"trainerSha1,omitempty" yaml:"trainerSha1,omitempty"`
+	Hashes      RomHashes     `json:"hashes" yaml:"hashes"`
+	Match       *GameEntry    `json:"match,omitempty" yaml:"match,omitempty"`
+}
+
+// cmdDecode implements `decode [files...]`.
+func cmdDecode(args []string) {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	dbPath := fs.String("db", "", "No-Intro/NES 2.0 database (JSON or DAT XML) to match ROMs against.")
+	extract := fs.String("extract", "", "Dump a region to a file: prg, chr, trainer, or inst.")
+	outPath := fs.String("o", "", "Output file for --extract (defaults to stdout). Only valid with a single input file.")
+	fs.Parse(args)
+	files := fs.Args()
+
+	if len(files) == 0 {
+		logger.Error("decode: no files given")
+		os.Exit(2)
+	}
+	if *extract != "" && *outPath != "" && len(files) > 1 {
+		logger.Error("--extract -o only supports a single input file; drop -o to write each region to stdout, or decode one file at a time")
+		os.Exit(2)
+	}
+
+	var db map[uint32]GameEntry
+	if *dbPath != "" {
+		var err error
+		db, err = loadDatabase(*dbPath)
+		check_error(err, fmt.Sprintf("** ERROR: Loading Database %s.\n\n", *dbPath))
+		logger.Debug("loaded database", "path", *dbPath, "entries", len(db))
+	}
+
+	for _, path := range files {
+		logger.Debug("decoding file", "path", path)
+
+		data, err := readAllInput(path)
+		check_error(err, fmt.Sprintf("** ERROR: Reading %s.\n\n", path))
+
+		header, layout, err := computeLayout(data)
+		check_error(err, fmt.Sprintf("** ERROR: Decoding Header For %s.\n\n", path))
+
+		prg, err := region(data, layout, "prg")
+		check_error(err, fmt.Sprintf("** ERROR: Reading PRG ROM From %s.\n\n", path))
+		chr, err := region(data, layout, "chr")
+		check_error(err, fmt.Sprintf("** ERROR: Reading CHR ROM From %s.\n\n", path))
+		hashes, err := hashROM(io.MultiReader(bytes.NewReader(prg), bytes.NewReader(chr)))
+		check_error(err, fmt.Sprintf("** ERROR: Hashing %s.\n\n", path))
+
+		result := DecodeResult{Path: path, Headerless: layout.Headerless, Header: decodeHeader(header), Hashes: hashes}
+		if match, ok := db[hashes.CRC32]; ok {
+			result.Match = &match
+		}
+		if layout.TrainerSize > 0 {
+			trainer, err := region(data, layout, "trainer")
+			check_error(err, fmt.Sprintf("** ERROR: Reading Trainer From %s.\n\n", path))
+			trainerHashes, err := hashROM(bytes.NewReader(trainer))
+			check_error(err, fmt.Sprintf("** ERROR: Hashing Trainer For %s.\n\n", path))
+			result.TrainerSHA1 = trainerHashes.SHA1
+		}
+
+		if layout.Headerless {
+			logger.Debug("no iNES magic found, guessed layout", "path", path)
+		}
+		if !isset_quiet && outputFormat == "text" {
+			print_good.Printf("\nInfo: Decoded %s.\n", path)
+		}
+		emit(result, func() {
+			printDecoded(result.Header)
+			if result.TrainerSHA1 != "" {
+				fmt.Printf("Trainer:    SHA1 %s\n", result.TrainerSHA1)
+			}
+			fmt.Printf("CRC32:      %08x\n", result.Hashes.CRC32)
+			fmt.Printf("SHA1:       %s\n", result.Hashes.SHA1)
+			fmt.Printf("MD5:        %s\n", result.Hashes.MD5)
+			if result.Match != nil {
+				printGameEntry(*result.Match)
+			}
+		})
+
+		if *extract != "" {
+			extracted, err := region(data, layout, *extract)
+			check_error(err, fmt.Sprintf("** ERROR: Extracting %s From %s.\n\n", *extract, path))
+			check_error(writeExtracted(*outPath, extracted), "** ERROR: Writing Extracted Region.\n\n")
+		}
+	}
+}
+
+// writeExtracted writes data to path, or to stdout when path is empty.
+func writeExtracted(path string, data []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// cmdVerify implements `verify <file>`.
+func cmdVerify(args []string) {
+	if len(args) != 1 {
+		logger.Error("verify takes exactly one file")
+		os.Exit(2)
+	}
+
+	f, err := openInput(args[0])
+	check_error(err, fmt.Sprintf("** ERROR: Opening %s.\n\n", args[0]))
+	defer f.Close()
+
+	header, err := readHeader(f)
+	if err != nil || string(header.Magic[:3]) != "NES" || header.Magic[3] != 0x1a {
+		logger.Error("not a valid NES file", "path", args[0])
+		os.Exit(1)
+	}
+
+	if !isset_quiet && outputFormat == "text" {
+		print_good.Printf("Info: %s Has A Valid Header.\n", args[0])
+	}
+}
+
+// cmdDiff implements `diff <a.nes> <b.nes>`.
+func cmdDiff(args []string) {
+	if len(args) != 2 {
+		logger.Error("diff takes exactly two files")
+		os.Exit(2)
+	}
+
+	fa, err := openInput(args[0])
+	check_error(err, fmt.Sprintf("** ERROR: Opening %s.\n\n", args[0]))
+	defer fa.Close()
+	fb, err := openInput(args[1])
+	check_error(err, fmt.Sprintf("** ERROR: Opening %s.\n\n", args[1]))
+	defer fb.Close()
+
+	headerA, err := readHeader(fa)
+	check_error(err, fmt.Sprintf("** ERROR: Decoding Header For %s.\n\n", args[0]))
+	headerB, err := readHeader(fb)
+	check_error(err, fmt.Sprintf("** ERROR: Decoding Header For %s.\n\n", args[1]))
+
+	decodedA := decodeHeader(headerA)
+	decodedB := decodeHeader(headerB)
+
+	type fieldDiff struct {
+		Field string      `json:"field" yaml:"field"`
+		A     interface{} `json:"a" yaml:"a"`
+		B     interface{} `json:"b" yaml:"b"`
+	}
+	diffs := []fieldDiff{}
+	jsonA, _ := json.Marshal(decodedA)
+	jsonB, _ := json.Marshal(decodedB)
+	var mapA, mapB map[string]interface{}
+	json.Unmarshal(jsonA, &mapA)
+	json.Unmarshal(jsonB, &mapB)
+
+	// Map iteration order is randomized, which would make diff output
+	// (and --json/--format scripting against it) non-deterministic
+	// across runs. Walk the fields in a fixed, sorted order instead.
+	fields := make([]string, 0, len(mapA))
+	for field := range mapA {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		valA, valB := mapA[field], mapB[field]
+		if fmt.Sprintf("%v", valA) != fmt.Sprintf("%v", valB) {
+			diffs = append(diffs, fieldDiff{Field: field, A: valA, B: valB})
+		}
+	}
+
+	emit(diffs, func() {
+		if len(diffs) == 0 {
+			if !isset_quiet {
+				print_good.Printf("Info: Headers Are Identical.\n")
+			}
+			return
+		}
+		for _, d := range diffs {
+			fmt.Printf("%-14s %v != %v\n", d.Field+":", d.A, d.B)
+		}
+	})
+}
+
+// cmdStrip implements `strip <file>`.
+func cmdStrip(args []string) {
+	fs := flag.NewFlagSet("strip", flag.ExitOnError)
+	out := fs.String("o", "", "Output file (defaults to stdout).")
+	fs.Parse(args)
+	rest := fs.Args()
+
+	if len(rest) != 1 {
+		logger.Error("strip takes exactly one file")
+		os.Exit(2)
+	}
+
+	f, err := openInput(rest[0])
+	check_error(err, fmt.Sprintf("** ERROR: Opening %s.\n\n", rest[0]))
+	defer f.Close()
+
+	header, err := readHeader(f)
+	if err != nil || string(header.Magic[:3]) != "NES" || header.Magic[3] != 0x1a {
+		logger.Error("not a valid NES file, refusing to strip a headerless ROM", "path", rest[0])
+		os.Exit(1)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		outFile, err := os.Create(*out)
+		check_error(err, fmt.Sprintf("** ERROR: Creating %s.\n\n", *out))
+		defer outFile.Close()
+		w = outFile
+	}
+
+	_, err = io.Copy(w, f)
+	check_error(err, "** ERROR: Writing Stripped ROM.\n\n")
+
+	if !isset_quiet && outputFormat == "text" && *out != "" {
+		print_good.Printf("Info: Wrote Stripped ROM To %s.\n", *out)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// NesHeader is the 16-byte header of an NES file, shared by iNES 1.0
+// and NES 2.0. Which fields are valid beyond Flags7 depends on the
+// format identified by Flags7 bits 2-3 (see detectFormat).
+type NesHeader struct {
+	Magic      [4]byte
+	PRGROMSize uint8
+	CHRROMSize uint8
+	Flags6     uint8
+	Flags7     uint8
+	Flags8     uint8
+	Flags9     uint8
+	Flags10    uint8
+	Flags11    uint8
+	Flags12    uint8
+	Flags13    uint8
+	Flags14    uint8
+	Flags15    uint8
+}
+
+// HeaderFormat identifies which header variant a NesHeader was written as.
+type HeaderFormat int
+
+const (
+	FormatUnknown HeaderFormat = iota
+	FormatINES1
+	FormatINES2
+)
+
+// ConsoleType is the console identified by Flags7 bits 0-1.
+type ConsoleType int
+
+const (
+	ConsoleNES ConsoleType = iota
+	ConsoleVsSystem
+	ConsolePlayChoice10
+	ConsoleExtended
+)
+
+var consoleTypeNames = map[ConsoleType]string{
+	ConsoleNES:          "NES/Famicom",
+	ConsoleVsSystem:     "Vs. System",
+	ConsolePlayChoice10: "Playchoice-10",
+	ConsoleExtended:     "Extended Console Type",
+}
+
+// mapperNames maps well-known mapper numbers to their common name.
+// Not exhaustive - covers the mappers that show up in the wild most often.
+var mapperNames = map[int]string{
+	0:   "NROM",
+	1:   "MMC1",
+	2:   "UxROM",
+	3:   "CNROM",
+	4:   "MMC3",
+	5:   "MMC5",
+	7:   "AxROM",
+	9:   "MMC2",
+	10:  "MMC4",
+	11:  "Color Dreams",
+	13:  "CPROM",
+	15:  "100-in-1 Contra Function 16",
+	16:  "Bandai FCG",
+	18:  "Jaleco SS8806",
+	19:  "Namco 163",
+	21:  "VRC4a/VRC4c",
+	22:  "VRC2a",
+	23:  "VRC2b/VRC4e",
+	24:  "VRC6a",
+	25:  "VRC4b/VRC4d",
+	26:  "VRC6b",
+	34:  "BNROM/NINA-001",
+	64:  "RAMBO-1",
+	66:  "GxROM",
+	69:  "Sunsoft FME-7",
+	71:  "Camerica/Codemasters",
+	79:  "NINA-03/NINA-06",
+	118: "TxSROM",
+	119: "TQROM",
+	163: "Nanjing",
+	210: "VRC2c/VRC4f",
+}
+
+// DecodedHeader is the fully-decoded, human-readable view of a NesHeader.
+type DecodedHeader struct {
+	Format        HeaderFormat `json:"format" yaml:"format"`
+	Mapper        int          `json:"mapper" yaml:"mapper"`
+	MapperName    string       `json:"mapperName" yaml:"mapperName"`
+	Submapper     int          `json:"submapper" yaml:"submapper"`
+	Mirroring     string       `json:"mirroring" yaml:"mirroring"`
+	Battery       bool         `json:"battery" yaml:"battery"`
+	Trainer       bool         `json:"trainer" yaml:"trainer"`
+	FourScreen    bool         `json:"fourScreen" yaml:"fourScreen"`
+	Console       ConsoleType  `json:"console" yaml:"console"`
+	PRGROMSizeKB  int          `json:"prgRomSizeKb" yaml:"prgRomSizeKb"`
+	CHRROMSizeKB  int          `json:"chrRomSizeKb" yaml:"chrRomSizeKb"`
+	PRGRAMSizeKB  int          `json:"prgRamSizeKb,omitempty" yaml:"prgRamSizeKb,omitempty"`
+	PRGRAMShift   uint8        `json:"prgRamShift" yaml:"prgRamShift"`
+	PRGNVRAMShift uint8        `json:"prgNvramShift" yaml:"prgNvramShift"`
+	CHRRAMShift   uint8        `json:"chrRamShift" yaml:"chrRamShift"`
+	CHRNVRAMShift uint8        `json:"chrNvramShift" yaml:"chrNvramShift"`
+	TVSystem      string       `json:"tvSystem" yaml:"tvSystem"`
+}
+
+// readHeader reads and decodes the 16-byte NesHeader from r.
+func readHeader(r io.Reader) (NesHeader, error) {
+	header := NesHeader{}
+	data := make([]byte, unsafe.Sizeof(header))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return header, err
+	}
+	buf := bytes.NewBuffer(data)
+	if err := binary.Read(buf, binary.LittleEndian, &header); err != nil {
+		return header, err
+	}
+	return header, nil
+}
+
+// detectFormat reports whether header was written as iNES 1.0 or NES 2.0.
+// NES 2.0 is identified by bits 2-3 of Flags7 being 0b10.
+func detectFormat(header NesHeader) HeaderFormat {
+	if header.Flags7&0x0c == 0x08 {
+		return FormatINES2
+	}
+	return FormatINES1
+}
+
+// decodeHeader turns a raw NesHeader into a DecodedHeader with every
+// documented field resolved, honoring the differences between iNES 1.0
+// and NES 2.0 along the way.
+func decodeHeader(header NesHeader) DecodedHeader {
+	format := detectFormat(header)
+
+	mapperLow := header.Flags6 >> 4
+	mapperMid := header.Flags7 >> 4
+	mapper := int(mapperLow) | int(mapperMid)<<4
+
+	submapper := 0
+	prgROMSize := int(header.PRGROMSize)
+	chrROMSize := int(header.CHRROMSize)
+	prgRAMSizeKB := 0
+	var prgRAMShift, prgNVRAMShift, chrRAMShift, chrNVRAMShift uint8
+	tvSystem := "NTSC"
+
+	if format == FormatINES2 {
+		mapperHi := header.Flags8 & 0x0f
+		mapper |= int(mapperHi) << 8
+		submapper = int(header.Flags8 >> 4)
+
+		prgROMSizeMSB := int(header.Flags9 & 0x0f)
+		chrROMSizeMSB := int(header.Flags9 >> 4)
+		prgROMSize |= prgROMSizeMSB << 8
+		chrROMSize |= chrROMSizeMSB << 8
+
+		prgRAMShift = header.Flags10 & 0x0f
+		prgNVRAMShift = header.Flags10 >> 4
+		chrRAMShift = header.Flags11 & 0x0f
+		chrNVRAMShift = header.Flags11 >> 4
+
+		switch header.Flags12 & 0x03 {
+		case 0:
+			tvSystem = "NTSC"
+		case 1:
+			tvSystem = "PAL"
+		default:
+			tvSystem = "Dual Compatible"
+		}
+	} else {
+		// iNES 1.0 carries the PRG RAM size directly in Flags8, in
+		// 8 KB units (byte8 of the header; "Flags8" in this struct).
+		prgRAMSizeKB = int(header.Flags8) * 8
+		if header.Flags9&0x01 != 0 {
+			tvSystem = "PAL"
+		}
+	}
+
+	mirroring := "Horizontal"
+	if header.Flags6&0x01 != 0 {
+		mirroring = "Vertical"
+	}
+	if header.Flags6&0x08 != 0 {
+		mirroring = "Four-Screen"
+	}
+
+	mapperName, ok := mapperNames[mapper]
+	if !ok {
+		mapperName = "Unknown"
+	}
+
+	return DecodedHeader{
+		Format:        format,
+		Mapper:        mapper,
+		MapperName:    mapperName,
+		Submapper:     submapper,
+		Mirroring:     mirroring,
+		Battery:       header.Flags6&0x02 != 0,
+		Trainer:       header.Flags6&0x04 != 0,
+		FourScreen:    header.Flags6&0x08 != 0,
+		Console:       ConsoleType(header.Flags7 & 0x03),
+		PRGROMSizeKB:  prgROMSize * 16,
+		CHRROMSizeKB:  chrROMSize * 8,
+		PRGRAMSizeKB:  prgRAMSizeKB,
+		PRGRAMShift:   prgRAMShift,
+		PRGNVRAMShift: prgNVRAMShift,
+		CHRRAMShift:   chrRAMShift,
+		CHRNVRAMShift: chrNVRAMShift,
+		TVSystem:      tvSystem,
+	}
+}
+
+// printDecoded prints a human-readable report of the decoded header.
+func printDecoded(decoded DecodedHeader) {
+	formatName := "iNES 1.0"
+	if decoded.Format == FormatINES2 {
+		formatName = "NES 2.0"
+	}
+
+	fmt.Printf("Format:     %s\n", formatName)
+	fmt.Printf("Mapper:     %d (%s)\n", decoded.Mapper, decoded.MapperName)
+	if decoded.Format == FormatINES2 {
+		fmt.Printf("Submapper:  %d\n", decoded.Submapper)
+	}
+	fmt.Printf("Mirroring:  %s\n", decoded.Mirroring)
+	fmt.Printf("Battery:    %v\n", decoded.Battery)
+	fmt.Printf("Trainer:    %v\n", decoded.Trainer)
+	fmt.Printf("Console:    %s\n", consoleTypeNames[decoded.Console])
+	fmt.Printf("PRG ROM:    %d KB\n", decoded.PRGROMSizeKB)
+	fmt.Printf("CHR ROM:    %d KB\n", decoded.CHRROMSizeKB)
+	if decoded.Format == FormatINES1 {
+		fmt.Printf("PRG RAM:    %d KB\n", decoded.PRGRAMSizeKB)
+	}
+	if decoded.Format == FormatINES2 {
+		fmt.Printf("PRG RAM:    2^%d bytes (shift count)\n", decoded.PRGRAMShift)
+		fmt.Printf("PRG NVRAM:  2^%d bytes (shift count)\n", decoded.PRGNVRAMShift)
+		fmt.Printf("CHR RAM:    2^%d bytes (shift count)\n", decoded.CHRRAMShift)
+		fmt.Printf("CHR NVRAM:  2^%d bytes (shift count)\n", decoded.CHRNVRAMShift)
+	}
+	fmt.Printf("TV System:  %s\n", decoded.TVSystem)
+}
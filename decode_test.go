@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestDecodeHeaderINES1PRGRAMSize(t *testing.T) {
+	header := NesHeader{
+		Magic:      [4]byte{'N', 'E', 'S', 0x1a},
+		PRGROMSize: 2,
+		CHRROMSize: 1,
+		Flags8:     4, // 4 * 8 KB = 32 KB PRG RAM
+	}
+
+	decoded := decodeHeader(header)
+
+	if decoded.Format != FormatINES1 {
+		t.Fatalf("Format = %v, want FormatINES1", decoded.Format)
+	}
+	if decoded.PRGRAMSizeKB != 32 {
+		t.Errorf("PRGRAMSizeKB = %d, want 32", decoded.PRGRAMSizeKB)
+	}
+}
+
+func TestDecodeHeaderNES2MapperAndSubmapper(t *testing.T) {
+	header := NesHeader{
+		Magic:      [4]byte{'N', 'E', 'S', 0x1a},
+		PRGROMSize: 2,
+		CHRROMSize: 1,
+		Flags6:     0x40, // mapper low nibble = 4
+		Flags7:     0x08, // NES 2.0 identifier bits, mapper high nibble = 0
+		Flags8:     0x21, // submapper = 2, mapper bits 8-11 = 1
+	}
+
+	decoded := decodeHeader(header)
+
+	if decoded.Format != FormatINES2 {
+		t.Fatalf("Format = %v, want FormatINES2", decoded.Format)
+	}
+	if decoded.Mapper != 0x104 {
+		t.Errorf("Mapper = %d, want %d", decoded.Mapper, 0x104)
+	}
+	if decoded.Submapper != 2 {
+		t.Errorf("Submapper = %d, want 2", decoded.Submapper)
+	}
+	// NES 2.0 headers don't carry the iNES 1.0 Flags8 PRG RAM size.
+	if decoded.PRGRAMSizeKB != 0 {
+		t.Errorf("PRGRAMSizeKB = %d, want 0 for NES 2.0", decoded.PRGRAMSizeKB)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parseSizeKB parses a human size like "128K" or "64KB" into kilobytes.
+// The result is always positive; callers that need it to also be a
+// multiple of a ROM unit size should check that separately.
+func parseSizeKB(s string) (int, error) {
+	trimmed := strings.ToUpper(strings.TrimSpace(s))
+	trimmed = strings.TrimSuffix(trimmed, "B")
+	trimmed = strings.TrimSuffix(trimmed, "K")
+	kb, err := strconv.Atoi(trimmed)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q, expected e.g. \"128K\"", s)
+	}
+	if kb <= 0 {
+		return 0, fmt.Errorf("invalid size %q, must be positive", s)
+	}
+	return kb, nil
+}
+
+// setMirroring rewrites the mirroring bits of Flags6 for "horizontal",
+// "vertical", or "four-screen".
+func setMirroring(header *NesHeader, mirroring string) error {
+	switch strings.ToLower(mirroring) {
+	case "horizontal":
+		header.Flags6 &^= 0x01
+		header.Flags6 &^= 0x08
+	case "vertical":
+		header.Flags6 |= 0x01
+		header.Flags6 &^= 0x08
+	case "four-screen", "fourscreen":
+		header.Flags6 |= 0x08
+	default:
+		return fmt.Errorf("unknown mirroring %q, expected horizontal, vertical, or four-screen", mirroring)
+	}
+	return nil
+}
+
+// setBattery rewrites the battery-backed PRG RAM bit of Flags6.
+func setBattery(header *NesHeader, battery bool) {
+	if battery {
+		header.Flags6 |= 0x02
+	} else {
+		header.Flags6 &^= 0x02
+	}
+}
+
+// setMapper rewrites the mapper number across Flags6, Flags7, and (for
+// NES 2.0) the low nibble of Flags8.
+func setMapper(header *NesHeader, mapper int) {
+	header.Flags6 = (header.Flags6 & 0x0f) | uint8(mapper&0x0f)<<4
+	header.Flags7 = (header.Flags7 & 0x0f) | uint8((mapper>>4)&0x0f)<<4
+	if detectFormat(*header) == FormatINES2 {
+		header.Flags8 = (header.Flags8 & 0xf0) | uint8((mapper>>8)&0x0f)
+	}
+}
+
+// maxROMUnits is the largest ROM size, in 16 KB (PRG) or 8 KB (CHR)
+// units, that fits in an iNES 1.0 size byte or a NES 2.0 size byte plus
+// its Flags9 MSB nibble.
+const (
+	maxROMUnitsINES1 = 0xff
+	maxROMUnitsNES2  = 0xfff
+)
+
+// setPRGROMKB rewrites PRGROMSize (and its NES 2.0 MSB nibble in Flags9)
+// from a total size in KB. kb must be a positive exact multiple of 16
+// and must fit in the header's size field.
+func setPRGROMKB(header *NesHeader, kb int) error {
+	if kb <= 0 || kb%16 != 0 {
+		return fmt.Errorf("--prg-rom size %dK must be a positive multiple of 16K", kb)
+	}
+	units := kb / 16
+	if max := maxUnitsFor(*header); units > max {
+		return fmt.Errorf("--prg-rom size %dK exceeds the maximum of %dK", kb, max*16)
+	}
+	header.PRGROMSize = uint8(units & 0xff)
+	if detectFormat(*header) == FormatINES2 {
+		header.Flags9 = (header.Flags9 & 0xf0) | uint8((units>>8)&0x0f)
+	}
+	return nil
+}
+
+// setCHRROMKB rewrites CHRROMSize (and its NES 2.0 MSB nibble in Flags9)
+// from a total size in KB. kb must be a positive exact multiple of 8 and
+// must fit in the header's size field.
+func setCHRROMKB(header *NesHeader, kb int) error {
+	if kb <= 0 || kb%8 != 0 {
+		return fmt.Errorf("--chr-rom size %dK must be a positive multiple of 8K", kb)
+	}
+	units := kb / 8
+	if max := maxUnitsFor(*header); units > max {
+		return fmt.Errorf("--chr-rom size %dK exceeds the maximum of %dK", kb, max*8)
+	}
+	header.CHRROMSize = uint8(units & 0xff)
+	if detectFormat(*header) == FormatINES2 {
+		header.Flags9 = (header.Flags9 & 0x0f) | uint8((units>>8)&0x0f)<<4
+	}
+	return nil
+}
+
+// maxUnitsFor reports the largest PRG/CHR unit count header's format can
+// represent: iNES 1.0 has an 8-bit size byte, NES 2.0 extends it with a
+// 4-bit MSB nibble in Flags9 for a 12-bit count.
+func maxUnitsFor(header NesHeader) int {
+	if detectFormat(header) == FormatINES2 {
+		return maxROMUnitsNES2
+	}
+	return maxROMUnitsINES1
+}
+
+// resolveROMSizesKB parses the requested --prg-rom/--chr-rom sizes (an
+// empty string keeps header's existing size for that region) and checks
+// the resulting total against data's actual body length (after the
+// header and, if present, the trainer). Without this check, a size that
+// claims more of the body than exists breaks later region() reads, and
+// a size that claims less silently strands real ROM data as invisible
+// trailing bytes - both corrupt the file with no other diagnostic.
+func resolveROMSizesKB(header NesHeader, data []byte, prgROM, chrROM string) (prgKB, chrKB int, err error) {
+	decoded := decodeHeader(header)
+	prgKB, chrKB = decoded.PRGROMSizeKB, decoded.CHRROMSizeKB
+
+	if prgROM != "" {
+		if prgKB, err = parseSizeKB(prgROM); err != nil {
+			return 0, 0, err
+		}
+	}
+	if chrROM != "" {
+		if chrKB, err = parseSizeKB(chrROM); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	trainerBytes := 0
+	if decoded.Trainer {
+		trainerBytes = trainerSize
+	}
+	bodyLen := len(data) - binary.Size(header) - trainerBytes
+	if claimed := (prgKB + chrKB) * kb; claimed != bodyLen {
+		return 0, 0, fmt.Errorf("PRG+CHR of %d KB would claim %d bytes of ROM data, but the file has %d bytes after the header", prgKB+chrKB, claimed, bodyLen)
+	}
+	return prgKB, chrKB, nil
+}
+
+// upgradeToNES2 promotes a valid iNES 1.0 header to NES 2.0 by setting
+// the format identifier bits in Flags7 and zero-extending the MSB
+// nibbles (submapper, mapper high bits, ROM size MSBs, RAM shift
+// counts) that iNES 1.0 doesn't carry.
+func upgradeToNES2(header *NesHeader) {
+	header.Flags7 = (header.Flags7 &^ 0x0c) | 0x08
+	header.Flags8 = 0
+	header.Flags9 = 0
+	header.Flags10 = 0
+	header.Flags11 = 0
+	header.Flags12 = 0
+	header.Flags13 = 0
+	header.Flags14 = 0
+	header.Flags15 = 0
+}
+
+// cmdSet implements `set`/`fix`, mutating header fields of a ROM on disk.
+func cmdSet(args []string) {
+	fs := flag.NewFlagSet("set", flag.ExitOnError)
+	mapper := fs.Int("mapper", -1, "Mapper number to set.")
+	mirroring := fs.String("mirroring", "", "Mirroring to set: horizontal, vertical, or four-screen.")
+	battery := fs.String("battery", "", "Battery-backed PRG RAM: true or false.")
+	prgROM := fs.String("prg-rom", "", "Total PRG ROM size, e.g. 128K.")
+	chrROM := fs.String("chr-rom", "", "Total CHR ROM size, e.g. 64K.")
+	upgrade := fs.Bool("upgrade-to-nes2", false, "Promote an iNES 1.0 header to NES 2.0.")
+	outPath := fs.String("o", "", "Output file (defaults to modifying in place with a .bak backup).")
+	fs.Parse(args)
+	rest := fs.Args()
+
+	if len(rest) != 1 {
+		logger.Error("set takes exactly one file")
+		os.Exit(2)
+	}
+	path := rest[0]
+
+	data, err := os.ReadFile(path)
+	check_error(err, fmt.Sprintf("** ERROR: Reading %s.\n\n", path))
+
+	if !isNesMagic(data) {
+		logger.Error("not a valid NES file, refusing to rewrite a headerless ROM", "path", path)
+		os.Exit(1)
+	}
+
+	header, err := readHeader(bytes.NewReader(data))
+	check_error(err, fmt.Sprintf("** ERROR: Decoding Header For %s.\n\n", path))
+
+	if *upgrade {
+		upgradeToNES2(&header)
+	}
+	if *mapper >= 0 {
+		setMapper(&header, *mapper)
+	}
+	if *mirroring != "" {
+		check_error(setMirroring(&header, *mirroring), "** ERROR: Invalid Mirroring.\n\n")
+	}
+	if *battery != "" {
+		b, err := strconv.ParseBool(*battery)
+		check_error(err, "** ERROR: --battery Must Be true or false.\n\n")
+		setBattery(&header, b)
+	}
+	if *prgROM != "" || *chrROM != "" {
+		prgKB, chrKB, err := resolveROMSizesKB(header, data, *prgROM, *chrROM)
+		check_error(err, fmt.Sprintf("** ERROR: --prg-rom/--chr-rom Doesn't Match %s's Actual Size.\n\n", path))
+		if *prgROM != "" {
+			check_error(setPRGROMKB(&header, prgKB), "** ERROR: Invalid --prg-rom Size.\n\n")
+		}
+		if *chrROM != "" {
+			check_error(setCHRROMKB(&header, chrKB), "** ERROR: Invalid --chr-rom Size.\n\n")
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	check_error(binary.Write(buf, binary.LittleEndian, &header), "** ERROR: Encoding Header.\n\n")
+	newData := append(buf.Bytes(), data[binary.Size(header):]...)
+
+	dest := path
+	if *outPath != "" {
+		dest = *outPath
+	} else {
+		check_error(os.WriteFile(path+".bak", data, 0644), "** ERROR: Writing Backup.\n\n")
+	}
+	check_error(os.WriteFile(dest, newData, 0644), fmt.Sprintf("** ERROR: Writing %s.\n\n", dest))
+
+	if !isset_quiet && outputFormat == "text" {
+		print_good.Printf("Info: Wrote Updated Header To %s.\n", dest)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeHeaderForTest encodes header into buf the same way cmdSet does.
+func writeHeaderForTest(t *testing.T, buf *bytes.Buffer, header NesHeader) {
+	t.Helper()
+	if err := binary.Write(buf, binary.LittleEndian, &header); err != nil {
+		t.Fatalf("binary.Write() error = %v", err)
+	}
+}
+
+func TestParseSizeKB(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"128K", 128, false},
+		{"64KB", 64, false},
+		{" 32k ", 32, false},
+		{"0K", 0, true},
+		{"-16K", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSizeKB(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSizeKB(%q) = %d, nil; want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSizeKB(%q) unexpected error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSizeKB(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSetPRGROMKBValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		kb      int
+		wantErr bool
+	}{
+		{"exact multiple", 32, false},
+		{"not a multiple of 16", 100, true},
+		{"negative", -160, true},
+		{"zero", 0, true},
+		{"too large for iNES 1.0", 256 * 16, true},
+	}
+	for _, tt := range tests {
+		header := NesHeader{}
+		err := setPRGROMKB(&header, tt.kb)
+		if tt.wantErr != (err != nil) {
+			t.Errorf("%s: setPRGROMKB(%d) error = %v, wantErr %v", tt.name, tt.kb, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSetCHRROMKBValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		kb      int
+		wantErr bool
+	}{
+		{"exact multiple", 16, false},
+		{"not a multiple of 8", 100, true},
+		{"negative", -80, true},
+		{"zero", 0, true},
+	}
+	for _, tt := range tests {
+		header := NesHeader{}
+		err := setCHRROMKB(&header, tt.kb)
+		if tt.wantErr != (err != nil) {
+			t.Errorf("%s: setCHRROMKB(%d) error = %v, wantErr %v", tt.name, tt.kb, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveROMSizesKB(t *testing.T) {
+	header := NesHeader{
+		Magic:      [4]byte{'N', 'E', 'S', 0x1a},
+		PRGROMSize: 2, // 32 KB
+		CHRROMSize: 1, // 8 KB
+	}
+	data := make([]byte, 16+32*kb+8*kb)
+
+	if _, _, err := resolveROMSizesKB(header, data, "", ""); err != nil {
+		t.Errorf("resolveROMSizesKB() with no overrides: unexpected error %v", err)
+	}
+	if _, _, err := resolveROMSizesKB(header, data, "64K", ""); err == nil {
+		t.Error("resolveROMSizesKB(64K) on a 32K-PRG ROM = nil error, want an error (would read past the body)")
+	}
+	if _, _, err := resolveROMSizesKB(header, data, "16K", ""); err == nil {
+		t.Error("resolveROMSizesKB(16K) on a 32K-PRG ROM = nil error, want an error (would strand real PRG data)")
+	}
+	prgKB, chrKB, err := resolveROMSizesKB(header, data, "16K", "24K")
+	if err != nil {
+		t.Fatalf("resolveROMSizesKB(16K, 24K) on a 32K+8K ROM: unexpected error %v", err)
+	}
+	if prgKB != 16 || chrKB != 24 {
+		t.Errorf("resolveROMSizesKB(16K, 24K) = %d, %d, want 16, 24", prgKB, chrKB)
+	}
+}
+
+func TestSetMapper(t *testing.T) {
+	// iNES 1.0: mapper is split across the top nibbles of Flags6/Flags7,
+	// so only the low 8 bits of mapper are representable.
+	header := NesHeader{}
+	setMapper(&header, 0x14)
+	if got := decodeHeader(header).Mapper; got != 0x14 {
+		t.Errorf("iNES 1.0 Mapper = %#x, want %#x", got, 0x14)
+	}
+
+	// NES 2.0: mapper also extends into the low nibble of Flags8.
+	header = NesHeader{Flags7: 0x08}
+	setMapper(&header, 0x104)
+	if got := decodeHeader(header).Mapper; got != 0x104 {
+		t.Errorf("NES 2.0 Mapper = %#x, want %#x", got, 0x104)
+	}
+}
+
+func TestComputeLayoutHeaderless(t *testing.T) {
+	data := make([]byte, 16*kb+8*kb)
+	_, layout, err := computeLayout(data)
+	if err != nil {
+		t.Fatalf("computeLayout() error = %v", err)
+	}
+	if !layout.Headerless {
+		t.Error("layout.Headerless = false, want true for a file with no NES\\x1a magic")
+	}
+	if layout.PRGSize != 16*kb || layout.CHRSize != 8*kb {
+		t.Errorf("layout = %+v, want PRGSize=%d CHRSize=%d", layout, 16*kb, 8*kb)
+	}
+}
+
+func TestComputeLayoutWithTrainer(t *testing.T) {
+	header := NesHeader{
+		Magic:      [4]byte{'N', 'E', 'S', 0x1a},
+		PRGROMSize: 1,
+		CHRROMSize: 1,
+		Flags6:     0x04, // trainer present
+	}
+	buf := &bytes.Buffer{}
+	writeHeaderForTest(t, buf, header)
+	data := append(buf.Bytes(), make([]byte, trainerSize+16*kb+8*kb)...)
+
+	_, layout, err := computeLayout(data)
+	if err != nil {
+		t.Fatalf("computeLayout() error = %v", err)
+	}
+	if layout.TrainerSize != trainerSize {
+		t.Errorf("layout.TrainerSize = %d, want %d", layout.TrainerSize, trainerSize)
+	}
+	if layout.PRGOffset != 16+trainerSize {
+		t.Errorf("layout.PRGOffset = %d, want %d", layout.PRGOffset, 16+trainerSize)
+	}
+}
+
+func TestCmdSetRewritesHeaderOnDisk(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rom.nes")
+	header := NesHeader{
+		Magic:      [4]byte{'N', 'E', 'S', 0x1a},
+		PRGROMSize: 2,
+		CHRROMSize: 1,
+	}
+	buf := &bytes.Buffer{}
+	writeHeaderForTest(t, buf, header)
+	body := bytes.Repeat([]byte{0xAB}, 2*16*kb+8*kb)
+	if err := os.WriteFile(path, append(buf.Bytes(), body...), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cmdSet([]string{"--mapper=4", path})
+
+	if _, err := os.Stat(path + ".bak"); err != nil {
+		t.Errorf("expected a .bak backup to be written, stat error = %v", err)
+	}
+	newData, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	newHeader, err := readHeader(bytes.NewReader(newData))
+	if err != nil {
+		t.Fatalf("readHeader() error = %v", err)
+	}
+	if got := decodeHeader(newHeader).Mapper; got != 4 {
+		t.Errorf("Mapper = %d, want 4", got)
+	}
+	if !bytes.Equal(newData[16:], body) {
+		t.Error("cmdSet corrupted ROM body data")
+	}
+}
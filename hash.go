@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RomHashes holds the digests of a ROM's PRG+CHR data, computed in a
+// single pass with io.MultiWriter.
+type RomHashes struct {
+	CRC32 uint32 `json:"crc32" yaml:"crc32"`
+	SHA1  string `json:"sha1" yaml:"sha1"`
+	MD5   string `json:"md5" yaml:"md5"`
+}
+
+// hashROM streams r through CRC32, SHA1, and MD5 simultaneously and
+// returns the resulting digests. r should be positioned just past the
+// 16-byte header, i.e. at the start of the PRG+CHR ROM data.
+func hashROM(r io.Reader) (RomHashes, error) {
+	crc := crc32.NewIEEE()
+	sha := sha1.New()
+	md := md5.New()
+	mw := io.MultiWriter(crc, sha, md)
+
+	if _, err := io.Copy(mw, r); err != nil {
+		return RomHashes{}, err
+	}
+
+	return RomHashes{
+		CRC32: crc.Sum32(),
+		SHA1:  hex.EncodeToString(sha.Sum(nil)),
+		MD5:   hex.EncodeToString(md.Sum(nil)),
+	}, nil
+}
+
+// GameEntry is a single No-Intro/NES 2.0 database record, keyed by the
+// CRC32 of its PRG+CHR ROM data.
+type GameEntry struct {
+	CRC32              uint32 `json:"-" yaml:"-"`
+	Name               string `json:"name" yaml:"name"`
+	Region             string `json:"region" yaml:"region"`
+	Revision           string `json:"revision" yaml:"revision"`
+	SuggestedMapper    int    `json:"suggestedMapper" yaml:"suggestedMapper"`
+	SuggestedMirroring string `json:"suggestedMirroring" yaml:"suggestedMirroring"`
+	SuggestedBattery   bool   `json:"suggestedBattery" yaml:"suggestedBattery"`
+	SuggestedPRGROMKB  int    `json:"suggestedPrgRomKb" yaml:"suggestedPrgRomKb"`
+	SuggestedCHRROMKB  int    `json:"suggestedChrRomKb" yaml:"suggestedChrRomKb"`
+}
+
+// gameEntryJSON mirrors GameEntry but carries CRC32 as the hex string
+// used by No-Intro JSON dumps rather than a uint32.
+type gameEntryJSON struct {
+	CRC32              string `json:"crc32"`
+	Name               string `json:"name"`
+	Region             string `json:"region"`
+	Revision           string `json:"revision"`
+	SuggestedMapper    int    `json:"suggestedMapper"`
+	SuggestedMirroring string `json:"suggestedMirroring"`
+	SuggestedBattery   bool   `json:"suggestedBattery"`
+	SuggestedPRGROMKB  int    `json:"suggestedPrgRomKb"`
+	SuggestedCHRROMKB  int    `json:"suggestedChrRomKb"`
+}
+
+// gameEntryXML mirrors the <game crc="..."> records used by No-Intro DAT
+// files.
+type gameEntryXML struct {
+	Name string `xml:"name,attr"`
+	ROM  struct {
+		CRC string `xml:"crc,attr"`
+	} `xml:"rom"`
+}
+
+type datXML struct {
+	Games []gameEntryXML `xml:"game"`
+}
+
+// loadDatabase loads a No-Intro/NES 2.0 game database from path, trying
+// JSON first and falling back to the No-Intro DAT XML format. The result
+// is keyed by CRC32 for O(1) lookup from hashROM's output.
+func loadDatabase(path string) (map[uint32]GameEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db := map[uint32]GameEntry{}
+
+	if strings.HasSuffix(strings.ToLower(path), ".xml") {
+		var dat datXML
+		if err := xml.Unmarshal(data, &dat); err != nil {
+			return nil, fmt.Errorf("parsing XML database: %w", err)
+		}
+		for _, g := range dat.Games {
+			crc, err := strconv.ParseUint(strings.TrimSpace(g.ROM.CRC), 16, 32)
+			if err != nil {
+				continue
+			}
+			db[uint32(crc)] = GameEntry{CRC32: uint32(crc), Name: g.Name}
+		}
+		return db, nil
+	}
+
+	var entries []gameEntryJSON
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing JSON database: %w", err)
+	}
+	for _, e := range entries {
+		crc, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(e.CRC32), "0x"), 16, 32)
+		if err != nil {
+			continue
+		}
+		db[uint32(crc)] = GameEntry{
+			CRC32:              uint32(crc),
+			Name:               e.Name,
+			Region:             e.Region,
+			Revision:           e.Revision,
+			SuggestedMapper:    e.SuggestedMapper,
+			SuggestedMirroring: e.SuggestedMirroring,
+			SuggestedBattery:   e.SuggestedBattery,
+			SuggestedPRGROMKB:  e.SuggestedPRGROMKB,
+			SuggestedCHRROMKB:  e.SuggestedCHRROMKB,
+		}
+	}
+	return db, nil
+}
+
+// printGameEntry prints a human-readable database match.
+func printGameEntry(entry GameEntry) {
+	fmt.Printf("Match:      %s\n", entry.Name)
+	if entry.Region != "" {
+		fmt.Printf("Region:     %s\n", entry.Region)
+	}
+	if entry.Revision != "" {
+		fmt.Printf("Revision:   %s\n", entry.Revision)
+	}
+	if entry.SuggestedMapper != 0 || entry.SuggestedMirroring != "" {
+		fmt.Printf("Suggested NES 2.0 header: mapper=%d mirroring=%s battery=%v prg=%dKB chr=%dKB\n",
+			entry.SuggestedMapper, entry.SuggestedMirroring, entry.SuggestedBattery,
+			entry.SuggestedPRGROMKB, entry.SuggestedCHRROMKB)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHashROM(t *testing.T) {
+	// The classic CRC32 check string, also used here to pin down the
+	// SHA1/MD5 digests against known-good vectors.
+	hashes, err := hashROM(strings.NewReader("123456789"))
+	if err != nil {
+		t.Fatalf("hashROM() error = %v", err)
+	}
+	if hashes.CRC32 != 0xcbf43926 {
+		t.Errorf("CRC32 = %08x, want %08x", hashes.CRC32, 0xcbf43926)
+	}
+	if hashes.SHA1 != "f7c3bc1d808e04732adf679965ccc34ca7ae3441" {
+		t.Errorf("SHA1 = %s, want f7c3bc1d808e04732adf679965ccc34ca7ae3441", hashes.SHA1)
+	}
+	if hashes.MD5 != "25f9e794323b453885f5181f1b624d0b" {
+		t.Errorf("MD5 = %s, want 25f9e794323b453885f5181f1b624d0b", hashes.MD5)
+	}
+}
+
+func TestLoadDatabaseJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	const doc = `[
+		{"crc32": "0xDEADBEEF", "name": "Good Game", "region": "USA", "suggestedMapper": 4},
+		{"crc32": "not-hex", "name": "Should Be Skipped"}
+	]`
+	if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	db, err := loadDatabase(path)
+	if err != nil {
+		t.Fatalf("loadDatabase() error = %v", err)
+	}
+	if len(db) != 1 {
+		t.Fatalf("len(db) = %d, want 1 (the bad-CRC entry should be silently skipped)", len(db))
+	}
+	entry, ok := db[0xDEADBEEF]
+	if !ok {
+		t.Fatal("db[0xDEADBEEF] missing")
+	}
+	if entry.Name != "Good Game" || entry.SuggestedMapper != 4 {
+		t.Errorf("entry = %+v, want Name=Good Game SuggestedMapper=4", entry)
+	}
+}
+
+func TestLoadDatabaseXML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.xml")
+	const doc = `<?xml version="1.0"?>
+<datafile>
+	<game name="Good Game"><rom crc="deadbeef"/></game>
+	<game name="Should Be Skipped"><rom crc="not-hex"/></game>
+</datafile>`
+	if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	db, err := loadDatabase(path)
+	if err != nil {
+		t.Fatalf("loadDatabase() error = %v", err)
+	}
+	if len(db) != 1 {
+		t.Fatalf("len(db) = %d, want 1 (the bad-CRC entry should be silently skipped)", len(db))
+	}
+	entry, ok := db[0xDEADBEEF]
+	if !ok {
+		t.Fatal("db[0xDEADBEEF] missing")
+	}
+	if entry.Name != "Good Game" {
+		t.Errorf("entry.Name = %q, want %q", entry.Name, "Good Game")
+	}
+}
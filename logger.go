@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+// logger is the package-wide structured logger for operational
+// messages (debug traces and errors). It's configured from the
+// -d/--quiet/--log-format global flags in parseGlobalFlags, kept
+// separate from the decoded-header output governed by --format. It
+// defaults to a plain text handler so callers that exercise a
+// subcommand directly (e.g. tests) never see a nil logger.
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// configureLogger builds the package logger. debug lowers the level to
+// include debug traces, quiet raises it to errors only, and format
+// selects between human-readable text and newline-delimited JSON.
+func configureLogger(debug, quiet bool, format string) {
+	level := slog.LevelInfo
+	switch {
+	case quiet:
+		level = slog.LevelError
+	case debug:
+		level = slog.LevelDebug
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+	logger = slog.New(handler)
+}
@@ -1,11 +1,32 @@
 /*
-nes-header-decoder Decodes NES files.
+nes-header-decoder Decodes, verifies, diffs, and strips NES file headers.
 
 Usage:
 
-	nes-header-decoder [flags] [file]
-
-The flags are:
+	nes-header-decoder [global flags] <command> [arguments]
+
+The commands are:
+
+	decode [files...]
+		Decode and print the header of one or more NES files.
+		Use "-" as a filename to read from stdin. Files lacking the
+		iNES magic are treated as headerless dumps and given a
+		best-guess header. Supports -db, and --extract=prg|chr|
+		trainer|inst to dump a region with -o.
+	verify <file>
+		Check that a file has a well-formed iNES/NES 2.0 header.
+		Exits non-zero if the header is invalid.
+	diff <a.nes> <b.nes>
+		Compare the decoded headers of two NES files field by field.
+	strip <file>
+		Remove the 16-byte header, writing the raw ROM data to stdout
+		or to the file given with -o.
+	set <file> (alias: fix)
+		Mutate header fields in place (--mapper, --mirroring,
+		--battery, --prg-rom, --chr-rom, --upgrade-to-nes2). Writes
+		a .bak backup unless -o is given.
+
+The global flags are:
 
 	-h
 		Show this help message.
@@ -13,220 +34,162 @@ The flags are:
 		Show version.
 	-d
 		Show debug messages.
-
-The file is:
-
-	An NES file to decode.
+	--json
+		Shorthand for --format=json.
+	--format={text,json,yaml}
+		Select the output format for decoded header data. Defaults
+		to text.
+	--log-format={text,json}
+		Select the format for diagnostic log messages. Defaults to
+		text.
+	--no-color
+		Disable colored output.
+	--quiet
+		Suppress informational output.
 
 Examples:
 
-	nes-header-decoder ./zelda.nes
-	nes-header-decoder -v
-	nes-header-decoder -d -v
-	nes-header-decoder -d -v -h
-	nes-header-decoder -d -v -h ./zelda.nes
+	nes-header-decoder decode ./zelda.nes
+	nes-header-decoder decode a.nes b.nes -
+	nes-header-decoder --json decode ./zelda.nes
+	nes-header-decoder verify ./zelda.nes
+	nes-header-decoder diff a.nes b.nes
+	nes-header-decoder strip -o stripped.bin ./zelda.nes
 */
 package main
 
 // Imports
 import (
-	"bytes"
-	"encoding/binary"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"log"
 	"os"
-	"unsafe"
+	"strings"
 
 	"github.com/fatih/color"
 )
 
-// Constants
-const (
-	KB = 1024 // 1 KB = 1024 bytes
-)
-
 // Global Variables
-var print_info = color.New(color.FgCyan)
-var print_error = color.New(color.FgRed)
-var print_debug = color.New(color.FgYellow)
 var print_good = color.New(color.FgGreen)
 var isset_debug = false
-var isset_filename = ""
+var isset_quiet = false
 var version = "0.0.1"
 
-// NesHeader is the header of an NES file.
-type NesHeader struct {
-	Magic      [4]byte
-	PRGROMSize uint8
-	CHRROMSize uint8
-	Flags6     uint8
-	Flags7     uint8
-	PRGRAMSize uint8
-	Flags9     uint8
-	Flags10    uint8
-	Zero       [5]byte
+// outputFormat is the format selected via --json/--format for decoded
+// header data, as distinct from --log-format for diagnostic messages.
+var outputFormat = "text"
+
+// commands maps a subcommand name to its implementation.
+var commands = map[string]func(args []string){
+	"decode": cmdDecode,
+	"verify": cmdVerify,
+	"diff":   cmdDiff,
+	"strip":  cmdStrip,
+	"set":    cmdSet,
+	"fix":    cmdSet,
 }
 
-// check_error checks for errors and exits if there is one.
-// It takes an error and a message to print if there is an error.
+// check_error logs err and exits if it is non-nil.
+// It takes an error and a message describing what was being attempted.
 func check_error(err error, msg string) {
 	if err != nil {
-		print_error.Printf(msg)
-		print_error.Printf("%v\n", err)
-		log.Fatalln(err)
+		logger.Error(strings.TrimRight(msg, "\n"), "error", err)
+		os.Exit(1)
 	}
 }
 
 // print_help_short prints the short help message.
 func print_help_short() {
-	fmt.Printf("Usage:\tnes-header-decoder [flags] [file]\n\n")
+	fmt.Printf("Usage:\tnes-header-decoder [global flags] <command> [arguments]\n\n")
 }
 
 // print_help prints the help message.
 func print_help() {
 	fmt.Printf("Usage:\n\n")
-	fmt.Printf("\tnes-header-decoder [flags] [file]\n\n")
-	fmt.Printf("The flags are:\n\n")
-	fmt.Printf("\t-h\tShow this help message.\n")
-	fmt.Printf("\t-v\tShow version.\n")
-	fmt.Printf("\t-d\tShow debug messages.\n\n")
-	fmt.Printf("The file is:\n\n")
-	fmt.Printf("\tAn NES file to decode.\n\n")
+	fmt.Printf("\tnes-header-decoder [global flags] <command> [arguments]\n\n")
+	fmt.Printf("The commands are:\n\n")
+	fmt.Printf("\tdecode [files...]\tDecode and print the header of one or more NES files.\n")
+	fmt.Printf("\tverify <file>\t\tCheck that a file has a well-formed header.\n")
+	fmt.Printf("\tdiff <a.nes> <b.nes>\tCompare the decoded headers of two NES files.\n")
+	fmt.Printf("\tstrip <file>\t\tRemove the 16-byte header.\n")
+	fmt.Printf("\tset <file>\t\tMutate header fields in place (alias: fix).\n\n")
+	fmt.Printf("The global flags are:\n\n")
+	fmt.Printf("\t-h\t\tShow this help message.\n")
+	fmt.Printf("\t-v\t\tShow version.\n")
+	fmt.Printf("\t-d\t\tShow debug messages.\n")
+	fmt.Printf("\t--json\t\tShorthand for --format=json.\n")
+	fmt.Printf("\t--format\tSelect the decoded header output format: text, json, or yaml.\n")
+	fmt.Printf("\t--log-format\tSelect the diagnostic log format: text or json.\n")
+	fmt.Printf("\t--no-color\tDisable colored output.\n")
+	fmt.Printf("\t--quiet\t\tSuppress informational output.\n\n")
 	fmt.Printf("Examples:\n\n")
-	fmt.Printf("\tnes-header-decoder ./zelda.nes\n")
-	fmt.Printf("\tnes-header-decoder -v\n")
-	fmt.Printf("\tnes-header-decoder -d -v\n")
-	fmt.Printf("\tnes-header-decoder -d -v -h\n")
-	fmt.Printf("\tnes-header-decoder -d -v -h ./zelda.nes\n\n")
+	fmt.Printf("\tnes-header-decoder decode ./zelda.nes\n")
+	fmt.Printf("\tnes-header-decoder decode a.nes b.nes -\n")
+	fmt.Printf("\tnes-header-decoder --json decode ./zelda.nes\n")
+	fmt.Printf("\tnes-header-decoder verify ./zelda.nes\n")
+	fmt.Printf("\tnes-header-decoder diff a.nes b.nes\n")
+	fmt.Printf("\tnes-header-decoder strip -o stripped.bin ./zelda.nes\n")
+	fmt.Printf("\tnes-header-decoder set --mapper=4 --battery=true zelda.nes\n\n")
 }
 
-// pretty prints a struct of nesheader
-func pretty(nesHeader NesHeader) {
-	pretty, err := json.MarshalIndent(nesHeader, "*", "    ")
-	if err != nil {
-		fmt.Println("Failed to generate json", err)
-	}
-	fmt.Printf("*%s\n", pretty)
-}
-
-func readNumBytes(f *os.File, numBytes int) []byte {
-	bytes := make([]byte, numBytes)
-	_, err := f.Read(bytes)
-	check_error(err, "** ERROR: Reading File.\n\n")
-	return bytes
-}
-
-// init is the first function to run.
-func init() {
-	// Check for args
-	if len(os.Args) < 2 {
-		print_error.Printf("** ERROR: No Args.\n")
-		print_help_short()
-		os.Exit(1)
-	}
-
-	// Print Header
-	print_good.Printf("=== NES Header Decoder ===\n")
-
-	// Check for flags
-	show_version := false
-	show_help := false
-	for n, args := range os.Args {
-		if n == 0 {
-			continue
-		}
-		switch args {
-		case "-h":
-			if len(os.Args) == 2 {
-				print_help()
-				os.Exit(0)
-			}
-			show_help = true
-		case "-v":
-			show_version = true
-		case "-d":
-			isset_debug = true
-		default:
-			isset_filename = args
-		}
+// parseGlobalFlags parses the global flags that precede the subcommand and
+// returns the subcommand name along with its remaining arguments.
+func parseGlobalFlags() (string, []string) {
+	fs := flag.NewFlagSet("nes-header-decoder", flag.ExitOnError)
+	showHelp := fs.Bool("h", false, "Show this help message.")
+	showVersion := fs.Bool("v", false, "Show version.")
+	fs.BoolVar(&isset_debug, "d", false, "Show debug messages.")
+	asJSON := fs.Bool("json", false, "Shorthand for --format=json.")
+	fs.StringVar(&outputFormat, "format", "text", "Decoded header output format: text, json, or yaml.")
+	logFormat := fs.String("log-format", "text", "Diagnostic log format: text or json.")
+	noColor := fs.Bool("no-color", false, "Disable colored output.")
+	fs.BoolVar(&isset_quiet, "quiet", false, "Suppress informational output.")
+	fs.Usage = print_help
+
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
 	}
 
-	// Print Args if Debug is on
-	if isset_debug {
-		for n, args := range os.Args {
-			print_debug.Printf("#%d - Args: %s\n", n, args)
-		}
+	if *noColor {
+		color.NoColor = true
 	}
-
-	// Check for filename
-	if isset_filename == "" {
-		print_error.Printf("** ERROR: No Filename.\n")
-		print_help_short()
-		os.Exit(2)
+	if *asJSON {
+		outputFormat = "json"
 	}
+	configureLogger(isset_debug, isset_quiet, *logFormat)
 
-	// Show Version if Enabled
-	if show_version {
-		print_info.Printf("Info: Version = %v\n", version)
+	logger.Debug("debug logging enabled")
+	if *showVersion {
+		// Requested output, not a diagnostic log line - print directly
+		// so it isn't swallowed by --quiet raising the log level.
+		fmt.Printf("Info: Version = %v\n", version)
 	}
-
-	// Show Help if Enabled
-	if show_help {
+	if *showHelp {
 		print_help()
+		os.Exit(0)
 	}
 
-	// Show Debug
-	if isset_debug {
-		print_debug.Printf("Debug: Enabled.\n")
+	args := fs.Args()
+	if len(args) == 0 {
+		logger.Error("no command given")
+		print_help_short()
+		os.Exit(1)
 	}
+	return args[0], args[1:]
 }
 
 // main is the main function.
 func main() {
-	// Announce
-	print_good.Printf("==========================\n")
-	print_info.Printf("\nInfo: Opening File.\n")
-	if isset_debug {
-		print_debug.Printf("Debug: Filename = %s\n", isset_filename)
-	}
+	cmd, args := parseGlobalFlags()
 
-	// Check File Exists
-	if _, err := os.Stat(isset_filename); os.IsNotExist(err) {
-		print_error.Printf("** ERROR: File Does Not Exist.\n")
-		os.Exit(3)
+	if !isset_quiet && outputFormat == "text" {
+		print_good.Printf("=== NES Header Decoder ===\n")
 	}
-	print_good.Printf("Info: File Exists.\n")
-
-	// Open and File
-	f, err := os.Open(isset_filename)
-	check_error(err, "** ERROR: Opening File.\n\n")
-	defer f.Close()
-
-	// Get File Size
-	fi, err := f.Stat()
-	check_error(err, "** ERROR: Getting File Size.\n\n")
-	if isset_debug {
-		print_debug.Printf("Debug: File Size = %d KB\n", fi.Size()/KB)
-	}
-
-	header := NesHeader{}
-	data := readNumBytes(f, int(unsafe.Sizeof(header)))
-	buf := bytes.NewBuffer(data)
-	err = binary.Read(buf, binary.LittleEndian, &header)
-	check_error(err, "** ERROR: Decoding Header.\n\n")
 
-	if isset_debug {
-		pretty(header)
+	run, ok := commands[cmd]
+	if !ok {
+		logger.Error("unknown command", "command", cmd)
+		print_help_short()
+		os.Exit(1)
 	}
-
-	print_good.Printf("\nInfo: Decoded Header.\n")
-	fmt.Printf("Magic:    %c%c%c x%02x\n", header.Magic[0], header.Magic[1], header.Magic[2], header.Magic[3])
-	fmt.Printf("PRG ROM:  %d KB\n", header.PRGROMSize*16)
-	fmt.Printf("CHR ROM:  %d KB\n", header.CHRROMSize*8)
-	fmt.Printf("Flags 6:  %08b\n", header.Flags6)
-	fmt.Printf("Flags 7:  %08b - (Mapper)\n", header.Flags7)
-	fmt.Printf("Flags 8:  %d KB - (PRG RAM Size)\n", header.PRGRAMSize*8)
-	fmt.Printf("Flags 9:  %08b\n", header.Flags9)
-	fmt.Printf("Flags 10: %08b\n", header.Flags10)
+	run(args)
 }
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	kb            = 1024
+	trainerSize   = 512
+	instROMSize   = 8 * kb
+	nes2ConsoleID = ConsolePlayChoice10
+)
+
+// RomLayout locates the trainer, PRG ROM, CHR ROM, and (for
+// Playchoice-10 dumps) the appended INST-ROM/PROM blocks within a ROM
+// file, in file-offset terms. A zero Size means the region is absent.
+type RomLayout struct {
+	Headerless    bool
+	HeaderSize    int
+	TrainerOffset int
+	TrainerSize   int
+	PRGOffset     int
+	PRGSize       int
+	CHROffset     int
+	CHRSize       int
+	InstROMOffset int
+	InstROMSize   int
+	PROMOffset    int
+	PROMSize      int
+}
+
+// isNesMagic reports whether data starts with the "NES\x1a" magic.
+func isNesMagic(data []byte) bool {
+	return len(data) >= 4 && data[0] == 'N' && data[1] == 'E' && data[2] == 'S' && data[3] == 0x1a
+}
+
+// prgUnitSizes and chrUnitSizes are the power-of-two PRG/CHR ROM counts
+// (in 16 KB/8 KB units respectively) seen in real-world dumps.
+var prgUnitSizes = []int{1, 2, 4, 8, 16, 32, 64, 128, 256}
+
+func isPowerOfTwoOrZero(n int) bool {
+	return n == 0 || n&(n-1) == 0
+}
+
+// guessHeaderless derives a best-guess header for a file that lacks the
+// iNES magic, by matching its size against common power-of-two PRG/CHR
+// ROM layouts (PRG in 16 KB units, CHR in 8 KB units).
+func guessHeaderless(size int) (NesHeader, bool) {
+	for _, prgUnits := range prgUnitSizes {
+		prgBytes := prgUnits * 16 * kb
+		if prgBytes > size {
+			break
+		}
+		remaining := size - prgBytes
+		if remaining%(8*kb) != 0 {
+			continue
+		}
+		chrUnits := remaining / (8 * kb)
+		if isPowerOfTwoOrZero(chrUnits) {
+			return NesHeader{
+				Magic:      [4]byte{'N', 'E', 'S', 0x1a},
+				PRGROMSize: uint8(prgUnits),
+				CHRROMSize: uint8(chrUnits),
+			}, true
+		}
+	}
+	return NesHeader{}, false
+}
+
+// computeLayout decodes (or, for headerless dumps, guesses) the header
+// for data and locates every region within it: the optional trainer,
+// PRG ROM, CHR ROM, and for Playchoice-10 ROMs the appended INST-ROM and
+// PROM blocks.
+func computeLayout(data []byte) (NesHeader, RomLayout, error) {
+	if isNesMagic(data) {
+		header, err := readHeader(bytes.NewReader(data))
+		if err != nil {
+			return header, RomLayout{}, err
+		}
+		decoded := decodeHeader(header)
+
+		layout := RomLayout{HeaderSize: 16}
+		offset := layout.HeaderSize
+		if decoded.Trainer {
+			layout.TrainerOffset = offset
+			layout.TrainerSize = trainerSize
+			offset += trainerSize
+		}
+
+		layout.PRGOffset = offset
+		layout.PRGSize = decoded.PRGROMSizeKB * kb
+		offset += layout.PRGSize
+
+		layout.CHROffset = offset
+		layout.CHRSize = decoded.CHRROMSizeKB * kb
+		offset += layout.CHRSize
+
+		if decoded.Console == nes2ConsoleID {
+			if remaining := len(data) - offset; remaining >= instROMSize {
+				layout.InstROMOffset = offset
+				layout.InstROMSize = instROMSize
+				offset += instROMSize
+			}
+			if remaining := len(data) - offset; remaining == 16 || remaining == 32 {
+				layout.PROMOffset = offset
+				layout.PROMSize = remaining
+			}
+		}
+
+		return header, layout, nil
+	}
+
+	header, ok := guessHeaderless(len(data))
+	if !ok {
+		return NesHeader{}, RomLayout{}, fmt.Errorf("could not guess a PRG/CHR ROM layout for a %d-byte headerless file", len(data))
+	}
+	decoded := decodeHeader(header)
+
+	return header, RomLayout{
+		Headerless: true,
+		PRGOffset:  0,
+		PRGSize:    decoded.PRGROMSizeKB * kb,
+		CHROffset:  decoded.PRGROMSizeKB * kb,
+		CHRSize:    decoded.CHRROMSizeKB * kb,
+	}, nil
+}
+
+// region extracts the named part of a ROM ("prg", "chr", "trainer", or
+// "inst") from data according to layout.
+func region(data []byte, layout RomLayout, name string) ([]byte, error) {
+	var offset, size int
+	switch name {
+	case "prg":
+		offset, size = layout.PRGOffset, layout.PRGSize
+	case "chr":
+		offset, size = layout.CHROffset, layout.CHRSize
+	case "trainer":
+		offset, size = layout.TrainerOffset, layout.TrainerSize
+	case "inst":
+		offset, size = layout.InstROMOffset, layout.InstROMSize
+	default:
+		return nil, fmt.Errorf("unknown region %q, expected prg, chr, trainer, or inst", name)
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("ROM has no %s region", name)
+	}
+	if offset+size > len(data) {
+		return nil, fmt.Errorf("%s region extends past end of file", name)
+	}
+	return data[offset : offset+size], nil
+}
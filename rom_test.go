@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestRegionTruncatedPRGReturnsError(t *testing.T) {
+	layout := RomLayout{PRGOffset: 0, PRGSize: 16 * kb}
+	data := make([]byte, 100)
+
+	if _, err := region(data, layout, "prg"); err == nil {
+		t.Fatal("region() = nil error, want an error for a truncated PRG region")
+	}
+}
+
+func TestRegionUnknownNameReturnsError(t *testing.T) {
+	if _, err := region(nil, RomLayout{}, "bogus"); err == nil {
+		t.Fatal("region() = nil error, want an error for an unknown region name")
+	}
+}
+
+func TestIsNesMagic(t *testing.T) {
+	valid := append([]byte{'N', 'E', 'S', 0x1a}, make([]byte, 16)...)
+	if !isNesMagic(valid) {
+		t.Error("isNesMagic() = false for a file starting with the NES\\x1a magic")
+	}
+
+	headerless := make([]byte, 16*kb+8*kb)
+	if isNesMagic(headerless) {
+		t.Error("isNesMagic() = true for a headerless dump with no magic bytes")
+	}
+}